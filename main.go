@@ -16,10 +16,17 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -30,68 +37,252 @@ var (
 	listen string
 	admin  string // Admin API...
 
-	provider string // s3, gcs, etc
+	provider string // s3, gcs, azure, fs
 	region   string
 	bucket   string
 	endpoint string // cloud API endpoint
+
+	mode     string // serve-only or cache-through; s3 provider only
+	upstream string // GOPROXY-style fallback list used by -mode=cache-through
+
+	azureAccount string
+	azureKey     string
+
+	dir string // root directory for the fs provider
+
+	adminKeysDir string // local directory for admin API access keys; overrides -provider for key storage
+	mintKey      bool   // mint a key and exit, bypassing the authenticated /keys endpoint
+
+	sumdbMode     string // off or proxy
+	sumdbUpstream string
+
+	presignTTL        time.Duration
+	presignExtensions string // comma-separated extensions to redirect, e.g. "zip"
 )
 
 func main() {
 	flag.StringVar(&listen, "listen", ":8080", "")
 	flag.StringVar(&admin, "admin", ":9999", "")
 
-	flag.StringVar(&provider, "provider", "s3", "")
+	flag.StringVar(&provider, "provider", "s3", "storage backend: s3, gcs, azure, or fs")
 	flag.StringVar(&region, "region", "", "")
-	flag.StringVar(&bucket, "bucket", "", "")
-	flag.StringVar(&endpoint, "endpoint", "", "")
+	flag.StringVar(&bucket, "bucket", "", "bucket or container name; unused by the fs provider")
+	flag.StringVar(&endpoint, "endpoint", "", "cloud API endpoint")
+
+	flag.StringVar(&mode, "mode", "serve-only", "s3 provider only: serve-only, or cache-through to fetch and cache missing modules from -upstream")
+	flag.StringVar(&upstream, "upstream", "https://proxy.golang.org", "GOPROXY-style comma/pipe-separated fallback list used by -mode=cache-through")
+
+	flag.StringVar(&azureAccount, "azure-account", "", "Azure storage account name, required by the azure provider")
+	flag.StringVar(&azureKey, "azure-key", "", "Azure storage account key, required by the azure provider")
+
+	flag.StringVar(&dir, "dir", "", "root directory, required by the fs provider")
+
+	flag.StringVar(&adminKeysDir, "admin-keys-dir", "", "local directory to store admin API access keys in; defaults to storing them alongside modules when -provider is s3 or fs; required by other providers")
+	flag.BoolVar(&mintKey, "mint-key", false, "mint a new admin API access key using the configured key store, print its credentials as JSON, and exit; use this to bootstrap the first key for an empty key store")
+
+	flag.StringVar(&sumdbMode, "sumdb-mode", "off", "sumdb handling: off or proxy; serve (re-signing records under a local key) is intentionally not supported, see SumDBProxy doc comment")
+	flag.StringVar(&sumdbUpstream, "sumdb-upstream", "https://sum.golang.org", "upstream GOSUMDB server")
+
+	flag.DurationVar(&presignTTL, "presign-ttl", 15*time.Minute, "how long a presigned download URL remains valid")
+	flag.StringVar(&presignExtensions, "presign-extensions", "zip", "comma-separated artifact extensions to redirect to a presigned URL instead of streaming; empty disables presigning")
 
 	flag.Parse()
 
 	log.SetPrefix("goproxy-s3: ")
 
-	if bucket == "" {
-		log.Fatalln("Please provide a bucket name")
+	keys, err := newKeyStore(provider)
+	if err != nil {
+		log.Fatalln(err)
 	}
 
-	var downloader proxy.Downloader
-	var copier proxy.Copier
-	switch provider {
-	case "s3":
-		cfg := &aws.Config{}
-		if region != "" {
-			cfg.Region = aws.String(region)
-		}
-		if endpoint != "" {
-			cfg.Endpoint = aws.String(endpoint)
-		}
-		sess, err := session.NewSession(cfg)
+	if mintKey {
+		ak, err := proxy.MintKey(keys)
 		if err != nil {
-			log.Fatalf("Cannot create AWS session: %v", err)
+			log.Fatalln(err)
 		}
-		copier = proxy.NewS3Copier(sess, bucket)
-		downloader = proxy.NewS3Downloader(sess, bucket)
-	default:
-		log.Fatalf("Unknown provider: %q", provider)
+		json.NewEncoder(os.Stdout).Encode(struct {
+			AccessKeyID string `json:"accessKeyId"`
+			SecretKey   string `json:"secretKey"`
+		}{
+			AccessKeyID: ak.AccessKeyID,
+			SecretKey:   hex.EncodeToString(ak.SecretKey),
+		})
+		return
 	}
 
+	backend, err := newBackend(provider)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	deleter, _ := backend.(proxy.ModuleDeleter)
+	versions, _ := backend.(proxy.VersionedBackend)
+
 	adminServer := http.Server{
-		Addr:    admin,
-		Handler: copier,
+		Addr: admin,
+		Handler: &proxy.AdminHandler{
+			Backend:  backend,
+			Deleter:  deleter,
+			Keys:     keys,
+			Versions: versions,
+		},
 	}
 	go func() {
 		log.Printf("Admin server is starting at %q", admin)
 		log.Fatalln(adminServer.ListenAndServe())
 	}()
 
+	proxyHandler := &proxy.ProxyHandler{Downloader: backend}
+	if exts := presignExtensionSet(presignExtensions); len(exts) > 0 {
+		presigner, ok := backend.(proxy.PresignDownloader)
+		if !ok {
+			log.Fatalf("provider %q does not support presigned downloads", provider)
+		}
+		proxyHandler.Presign = presigner
+		proxyHandler.PresignTTL = presignTTL
+		proxyHandler.PresignExtensions = exts
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", proxyHandler)
+	if sumdbMode != "off" {
+		sumdb, err := newSumDBProxy(backend)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		mux.Handle("/sumdb/", sumdb)
+	}
+
 	server := http.Server{
 		Addr: listen,
 		TLSConfig: &tls.Config{
 			InsecureSkipVerify: true, // TODO(jbd): Support TLS options.
 		},
-		Handler: &proxy.ProxyHandler{
-			Downloader: downloader,
-		},
+		Handler: mux,
 	}
 	log.Printf("Proxy server is starting at %q; set GOPROXY", listen)
 	log.Fatalln(server.ListenAndServe())
 }
+
+// presignExtensionSet parses a comma-separated list of extensions (with or
+// without a leading dot) into the set ProxyHandler.PresignExtensions
+// expects.
+func presignExtensionSet(list string) map[string]bool {
+	exts := map[string]bool{}
+	for _, e := range strings.Split(list, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		exts["."+strings.TrimPrefix(e, ".")] = true
+	}
+	return exts
+}
+
+// newSumDBProxy builds the sumdb subsystem selected by -sumdb-mode. backend
+// must also implement proxy.SumDBCache.
+func newSumDBProxy(backend proxy.Backend) (*proxy.SumDBProxy, error) {
+	cache, ok := backend.(proxy.SumDBCache)
+	if !ok {
+		return nil, fmt.Errorf("provider %q does not support sumdb caching", provider)
+	}
+
+	var mode proxy.SumDBMode
+	switch sumdbMode {
+	case "proxy":
+		mode = proxy.SumDBProxyMode
+	default:
+		return nil, fmt.Errorf("unknown -sumdb-mode %q", sumdbMode)
+	}
+	return proxy.NewSumDBProxy(mode, sumdbUpstream, cache), nil
+}
+
+// newKeyStore builds the KeyStore that persists admin API access keys. If
+// -admin-keys-dir is set, keys are stored on the local filesystem;
+// otherwise they're stored alongside modules by the s3 provider (in its
+// bucket) or the fs provider (under -dir). Other providers require
+// -admin-keys-dir.
+func newKeyStore(provider string) (proxy.KeyStore, error) {
+	if adminKeysDir != "" {
+		return proxy.NewFSKeyStore(adminKeysDir), nil
+	}
+
+	switch provider {
+	case "s3":
+		if bucket == "" {
+			log.Fatalln("Please provide a bucket name")
+		}
+		cfg := &aws.Config{}
+		if region != "" {
+			cfg.Region = aws.String(region)
+		}
+		if endpoint != "" {
+			cfg.Endpoint = aws.String(endpoint)
+		}
+		sess, err := session.NewSession(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return proxy.NewS3KeyStore(sess, bucket), nil
+
+	case "fs":
+		if dir == "" {
+			log.Fatalln("Please provide a -dir for the fs provider")
+		}
+		return proxy.NewFSKeyStore(dir), nil
+
+	default:
+		return nil, fmt.Errorf("provider %q requires -admin-keys-dir to store admin API access keys", provider)
+	}
+}
+
+// newBackend builds the storage backend selected by -provider.
+func newBackend(provider string) (proxy.Backend, error) {
+	switch provider {
+	case "s3":
+		if bucket == "" {
+			log.Fatalln("Please provide a bucket name")
+		}
+		cfg := &aws.Config{}
+		if region != "" {
+			cfg.Region = aws.String(region)
+		}
+		if endpoint != "" {
+			cfg.Endpoint = aws.String(endpoint)
+		}
+		sess, err := session.NewSession(cfg)
+		if err != nil {
+			return nil, err
+		}
+		switch mode {
+		case "serve-only":
+			return proxy.NewS3Backend(sess, bucket), nil
+		case "cache-through":
+			return proxy.NewFallthroughBackend(sess, bucket, upstream), nil
+		default:
+			log.Fatalf("Unknown -mode: %q", mode)
+			return nil, nil
+		}
+
+	case "gcs":
+		if bucket == "" {
+			log.Fatalln("Please provide a bucket name")
+		}
+		return proxy.NewGCSBackend(context.Background(), bucket)
+
+	case "azure":
+		if azureAccount == "" || azureKey == "" || bucket == "" {
+			log.Fatalln("Please provide -azure-account, -azure-key, and -bucket (container name)")
+		}
+		return proxy.NewAzureBackend(azureAccount, azureKey, bucket)
+
+	case "fs":
+		if dir == "" {
+			log.Fatalln("Please provide a -dir for the fs provider")
+		}
+		return proxy.NewFSBackend(dir), nil
+
+	default:
+		log.Fatalf("Unknown provider: %q", provider)
+		return nil, nil
+	}
+}
@@ -0,0 +1,307 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+const (
+	adminAuthScheme  = "GOPROXY-HMAC-SHA256"
+	adminDateHeader  = "X-Goproxy-Date"
+	adminNonceHeader = "X-Goproxy-Nonce"
+	adminMaxSkew     = 5 * time.Minute
+)
+
+var errUnauthorized = errors.New("unauthorized")
+
+// AdminHandler exposes a REST surface for managing modules and admin API
+// access keys:
+//
+//	POST   /modules/{path}@{version}           copy a module into Backend
+//	DELETE /modules/{path}@{version}           remove a module's artifacts
+//	GET    /modules/{path}/versions            list a module's known versions
+//	GET    /modules/{path}@{version}/history   list an artifact's stored revisions
+//	POST   /modules/{path}@{version}/rollback  restore a prior artifact revision
+//	POST   /keys                               mint a new access key
+//
+// Every request must carry an Authorization header signed with a key from
+// Keys, plus an X-Goproxy-Date and a unique X-Goproxy-Nonce; see
+// SignRequest.
+type AdminHandler struct {
+	Backend  Backend
+	Deleter  ModuleDeleter // optional; nil disables DELETE
+	Keys     KeyStore
+	Versions VersionedBackend // optional; nil disables /history and /rollback
+
+	nonceMu sync.Mutex
+	nonces  map[string]time.Time // accessKeyID+"/"+nonce -> expiry; rejects replayed requests
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.authenticate(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/keys":
+		h.mintKey(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/modules/") && strings.HasSuffix(r.URL.Path, "/versions"):
+		h.listVersions(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/modules/") && strings.HasSuffix(r.URL.Path, "/history"):
+		h.history(w, r)
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/modules/") && strings.HasSuffix(r.URL.Path, "/rollback"):
+		h.rollback(w, r)
+	case strings.HasPrefix(r.URL.Path, "/modules/"):
+		h.module(w, r)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (h *AdminHandler) module(w http.ResponseWriter, r *http.Request) {
+	path, version, ok := parseURLPathForModule(strings.TrimPrefix(r.URL.Path, "/modules/"))
+	if !ok {
+		http.Error(w, "malformed module path or version", http.StatusBadRequest)
+		return
+	}
+	m := module.Version{Path: path, Version: version}
+
+	switch r.Method {
+	case http.MethodPost:
+		force := r.URL.Query().Get("f") == "true"
+		if err := h.Backend.Copy(force, m); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+
+	case http.MethodDelete:
+		if h.Deleter == nil {
+			http.Error(w, "provider does not support deletion", http.StatusNotImplemented)
+			return
+		}
+		if err := h.Deleter.DeleteModule(m); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, os.ErrNotExist) {
+				code = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *AdminHandler) listVersions(w http.ResponseWriter, r *http.Request) {
+	modulePath := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/modules/"), "/versions")
+
+	versions, err := h.Backend.ListVersions(modulePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	json.NewEncoder(w).Encode(versions)
+}
+
+// artifactExtensions are the artifact names whose revision history h.history
+// reports for a module version.
+var artifactExtensions = []string{".info", ".mod", ".zip", ".ziphash"}
+
+func (h *AdminHandler) history(w http.ResponseWriter, r *http.Request) {
+	if h.Versions == nil {
+		http.Error(w, "provider does not support version history", http.StatusNotImplemented)
+		return
+	}
+
+	path, version, ok := parseURLPathForModule(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/modules/"), "/history"))
+	if !ok {
+		http.Error(w, "malformed module path or version", http.StatusBadRequest)
+		return
+	}
+
+	history := map[string][]ArtifactVersion{}
+	for _, ext := range artifactExtensions {
+		name := version + ext
+		versions, err := h.Versions.History(path, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(versions) > 0 {
+			history[name] = versions
+		}
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	json.NewEncoder(w).Encode(history)
+}
+
+func (h *AdminHandler) rollback(w http.ResponseWriter, r *http.Request) {
+	if h.Versions == nil {
+		http.Error(w, "provider does not support rollback", http.StatusNotImplemented)
+		return
+	}
+
+	path, version, ok := parseURLPathForModule(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/modules/"), "/rollback"))
+	if !ok {
+		http.Error(w, "malformed module path or version", http.StatusBadRequest)
+		return
+	}
+
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		http.Error(w, "missing ?to=<versionId>", http.StatusBadRequest)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = version + ".zip"
+	}
+
+	if err := h.Versions.Rollback(path, name, to); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+func (h *AdminHandler) mintKey(w http.ResponseWriter, r *http.Request) {
+	ak, err := MintKey(h.Keys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	json.NewEncoder(w).Encode(struct {
+		AccessKeyID string `json:"accessKeyId"`
+		SecretKey   string `json:"secretKey"`
+	}{
+		AccessKeyID: ak.AccessKeyID,
+		SecretKey:   hex.EncodeToString(ak.SecretKey),
+	})
+}
+
+// authenticate verifies the Authorization header of r against Keys,
+// similar to S3's SigV4 flow: the client signs method, path, query,
+// nonce, and a timestamp with its secret key, and the signature is
+// recomputed here and compared in constant time. The nonce is also
+// checked against previously seen nonces for the same key so a captured
+// request can't be replayed within the skew window.
+func (h *AdminHandler) authenticate(r *http.Request) error {
+	scheme, accessKeyID, signature, ok := parseAuthHeader(r.Header.Get("Authorization"))
+	if !ok || scheme != adminAuthScheme {
+		return errUnauthorized
+	}
+
+	date, err := time.Parse(time.RFC3339, r.Header.Get(adminDateHeader))
+	if err != nil {
+		return errUnauthorized
+	}
+	if skew := time.Since(date); skew < -adminMaxSkew || skew > adminMaxSkew {
+		return errUnauthorized
+	}
+
+	nonce := r.Header.Get(adminNonceHeader)
+	if nonce == "" {
+		return errUnauthorized
+	}
+
+	ak, err := h.Keys.Get(accessKeyID)
+	if errors.Is(err, os.ErrNotExist) {
+		return errUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	want := SignRequest(ak, r.Method, r.URL.Path, r.URL.RawQuery, nonce, date)
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return errUnauthorized
+	}
+
+	if !h.claimNonce(accessKeyID, nonce, date) {
+		return errUnauthorized
+	}
+	return nil
+}
+
+// claimNonce reports whether nonce hasn't already been used by
+// accessKeyID within the current skew window, recording it as used if
+// so. Expired entries are swept opportunistically on each call.
+func (h *AdminHandler) claimNonce(accessKeyID, nonce string, date time.Time) bool {
+	h.nonceMu.Lock()
+	defer h.nonceMu.Unlock()
+
+	if h.nonces == nil {
+		h.nonces = map[string]time.Time{}
+	}
+	now := time.Now()
+	for k, expiry := range h.nonces {
+		if now.After(expiry) {
+			delete(h.nonces, k)
+		}
+	}
+
+	key := accessKeyID + "/" + nonce
+	if _, seen := h.nonces[key]; seen {
+		return false
+	}
+	h.nonces[key] = date.Add(adminMaxSkew)
+	return true
+}
+
+// SignRequest computes the signature a client must send in the
+// Authorization header to authenticate method, path, query, and nonce as
+// ak at date. The same date and nonce must also be sent verbatim in the
+// X-Goproxy-Date and X-Goproxy-Nonce headers, with date formatted using
+// time.RFC3339 and nonce unique per request.
+func SignRequest(ak *AccessKey, method, path, query, nonce string, date time.Time) string {
+	mac := hmac.New(sha256.New, ak.SecretKey)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, path, query, nonce, date.UTC().Format(time.RFC3339))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseAuthHeader parses an Authorization header of the form
+// "<scheme> AccessKey=<id>, Signature=<sig>".
+func parseAuthHeader(h string) (scheme, accessKeyID, signature string, ok bool) {
+	i := strings.Index(h, " ")
+	if i < 0 {
+		return "", "", "", false
+	}
+	scheme, rest := h[:i], h[i+1:]
+	for _, kv := range strings.Split(rest, ",") {
+		kv = strings.TrimSpace(kv)
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		switch kv[:i] {
+		case "AccessKey":
+			accessKeyID = kv[i+1:]
+		case "Signature":
+			signature = kv[i+1:]
+		}
+	}
+	return scheme, accessKeyID, signature, scheme != "" && accessKeyID != "" && signature != ""
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"golang.org/x/mod/module"
+)
+
+// AzureBackend serves and populates module artifacts backed by an Azure
+// Blob Storage container. Use NewAzureBackend to initialize one.
+type AzureBackend struct {
+	container azblob.ContainerURL
+}
+
+func NewAzureBackend(account, key, container string) (*AzureBackend, error) {
+	cred, err := azblob.NewSharedKeyCredential(account, key)
+	if err != nil {
+		return nil, err
+	}
+	pipeline := azblob.NewPipeline(cred, azblob.PipelineOptions{})
+
+	u, err := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s", account, container))
+	if err != nil {
+		return nil, err
+	}
+	return &AzureBackend{
+		container: azblob.NewContainerURL(*u, pipeline),
+	}, nil
+}
+
+func (b *AzureBackend) blob(modulePath, name string) azblob.BlockBlobURL {
+	return b.container.NewBlockBlobURL(fmt.Sprintf("modules/%s/@v/%s", modulePath, name))
+}
+
+// Download downloads a module artifact from the container. modulePath is
+// the import path of the module, e.g. golang.org/x/text. name is the
+// asset's name such as v0.3.0.info, v0.3.0.mod, v0.3.0.ziphash, or
+// v0.3.0.zip.
+func (b *AzureBackend) Download(modulePath string, name string) (io.ReadCloser, error) {
+	resp, err := b.blob(modulePath, name).Download(context.Background(), 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body(azblob.RetryReaderOptions{}), nil
+}
+
+// HeadObject reports whether the named artifact exists in the container.
+func (b *AzureBackend) HeadObject(modulePath string, name string) (bool, error) {
+	return blobExists(b.blob(modulePath, name))
+}
+
+// ListKeys lists the artifact names stored under modulePath's @v directory.
+func (b *AzureBackend) ListKeys(modulePath string) ([]string, error) {
+	prefix := fmt.Sprintf("modules/%s/@v/", modulePath)
+
+	var keys []string
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := b.container.ListBlobsFlatSegment(context.Background(), marker, azblob.ListBlobsSegmentOptions{
+			Prefix: prefix,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Segment.BlobItems {
+			keys = append(keys, item.Name[len(prefix):])
+		}
+		marker = resp.NextMarker
+	}
+	return keys, nil
+}
+
+// ListVersions returns the known versions of modulePath.
+func (b *AzureBackend) ListVersions(modulePath string) ([]string, error) {
+	keys, err := b.ListKeys(modulePath)
+	if err != nil {
+		return nil, err
+	}
+	return versionsFromInfoKeys(keys), nil
+}
+
+// GetSumDB fetches a cached checksum database record stored at key. It
+// returns an error satisfying errors.Is(err, os.ErrNotExist) on a cache
+// miss.
+func (b *AzureBackend) GetSumDB(key string) ([]byte, error) {
+	resp, err := b.container.NewBlockBlobURL(key).Download(context.Background(), 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if stgErr, ok := err.(azblob.StorageError); ok && stgErr.ServiceCode() == azblob.ServiceCodeBlobNotFound {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body(azblob.RetryReaderOptions{})
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// PutSumDB caches a checksum database record at key.
+func (b *AzureBackend) PutSumDB(key string, data []byte) error {
+	_, err := azblob.UploadBufferToBlockBlob(context.Background(), data, b.container.NewBlockBlobURL(key), azblob.UploadToBlockBlobOptions{})
+	return err
+}
+
+// Copy will run go mod download locally for the given
+// module and upload artifacts to the container. Copy will
+// ensure all transient dependencies are copied.
+func (b *AzureBackend) Copy(force bool, m module.Version) error {
+	return copyModule(force, m, b.exists, b.upload)
+}
+
+func (b *AzureBackend) exists(key string) (bool, error) {
+	return blobExists(b.container.NewBlockBlobURL(key))
+}
+
+func blobExists(blob azblob.BlockBlobURL) (bool, error) {
+	_, err := blob.GetProperties(context.Background(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if stgErr, ok := err.(azblob.StorageError); ok && stgErr.ServiceCode() == azblob.ServiceCodeBlobNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *AzureBackend) upload(key string, f *os.File) error {
+	_, err := azblob.UploadFileToBlockBlob(context.Background(), f, b.container.NewBlockBlobURL(key), azblob.UploadToBlockBlobOptions{})
+	return err
+}
+
+// DeleteModule removes every artifact stored for m, e.g. its .info, .mod,
+// .zip, and .ziphash.
+func (b *AzureBackend) DeleteModule(m module.Version) error {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("modules/%s/@v/%s.", m.Path, m.Version)
+
+	var deleted int
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := b.container.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Prefix: prefix,
+		})
+		if err != nil {
+			return err
+		}
+		for _, item := range resp.Segment.BlobItems {
+			if _, err := b.container.NewBlockBlobURL(item.Name).Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{}); err != nil {
+				return err
+			}
+			deleted++
+		}
+		marker = resp.NextMarker
+	}
+	if deleted == 0 {
+		return os.ErrNotExist
+	}
+	return nil
+}
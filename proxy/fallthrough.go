@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"golang.org/x/mod/module"
+)
+
+// upstreamProxy is one entry of a GOPROXY-style fallback list: a base URL
+// and whether it should only be tried after the previous entry returned a
+// "not found" style error, as a comma (",") separator requires, as opposed
+// to any error, as a pipe ("|") separator allows.
+type upstreamProxy struct {
+	url            string
+	onlyOnNotFound bool
+}
+
+// parseGOPROXYList parses a GOPROXY-style comma/pipe separated list of
+// proxy base URLs, e.g. "https://a.example,https://b.example|https://c.example".
+// "off" disables fetching entirely and yields an empty list.
+func parseGOPROXYList(s string) []upstreamProxy {
+	if s == "" || s == "off" {
+		return nil
+	}
+
+	var list []upstreamProxy
+	onlyOnNotFound := false
+	for len(s) > 0 {
+		part := s
+		pipe := false
+		if i := strings.IndexAny(s, ",|"); i >= 0 {
+			part = s[:i]
+			pipe = s[i] == '|'
+			s = s[i+1:]
+		} else {
+			s = ""
+		}
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, upstreamProxy{url: strings.TrimSuffix(part, "/"), onlyOnNotFound: onlyOnNotFound})
+		}
+		onlyOnNotFound = !pipe
+	}
+	return list
+}
+
+// FallthroughDownloader wraps an S3Downloader and, on a cache miss, fetches
+// the missing artifact from an upstream GOPROXY-compatible proxy (see
+// https://proxy.golang.org), returning it to the caller and caching it in
+// S3 via s3manager.Uploader so subsequent requests are served from the
+// bucket. Use NewFallthroughDownloader to initialize one.
+type FallthroughDownloader struct {
+	*S3Downloader
+
+	bucket   string
+	uploader *s3manager.Uploader
+	upstream []upstreamProxy
+	client   *http.Client
+}
+
+// NewFallthroughDownloader initializes a FallthroughDownloader backed by
+// bucket, falling through to the GOPROXY-style list of proxies in
+// upstream on a cache miss.
+func NewFallthroughDownloader(s *session.Session, bucket, upstream string) *FallthroughDownloader {
+	return &FallthroughDownloader{
+		S3Downloader: NewS3Downloader(s, bucket),
+		bucket:       bucket,
+		uploader:     s3manager.NewUploader(s),
+		upstream:     parseGOPROXYList(upstream),
+		client:       http.DefaultClient,
+	}
+}
+
+// Download serves modulePath/name from S3 if it's already cached there;
+// otherwise it fetches the artifact from the configured upstream
+// proxies, caches it in S3 for subsequent requests, and returns it to the
+// caller.
+func (d *FallthroughDownloader) Download(modulePath string, name string) (io.ReadCloser, error) {
+	r, err := d.S3Downloader.Download(modulePath, name)
+	if err == nil {
+		return r, nil
+	}
+	if aerr, ok := err.(awserr.Error); !ok || (aerr.Code() != errCodeNotFound && aerr.Code() != s3.ErrCodeNoSuchKey) {
+		return nil, err
+	}
+
+	data, err := d.fetch(modulePath, name)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("modules/%s/@v/%s", modulePath, name)
+	if _, err := d.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}); err != nil {
+		log.Printf("caching %q: %v", key, err)
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// ListVersions returns the known versions of modulePath. It prefers the
+// versions already cached in S3, and falls through to the upstream
+// proxies if none are cached yet.
+func (d *FallthroughDownloader) ListVersions(modulePath string) ([]string, error) {
+	versions, err := d.S3Downloader.ListVersions(modulePath)
+	if err != nil || len(versions) > 0 {
+		return versions, err
+	}
+
+	data, err := d.fetch(modulePath, "list")
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if v != "" {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+// fetch requests modulePath's name artifact from the configured upstream
+// proxies in order, honoring the pipe/comma fallthrough semantics
+// described by parseGOPROXYList.
+func (d *FallthroughDownloader) fetch(modulePath, name string) ([]byte, error) {
+	if len(d.upstream) == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for i, up := range d.upstream {
+		if i > 0 && up.onlyOnNotFound && lastErr != os.ErrNotExist {
+			return nil, lastErr
+		}
+
+		data, err := d.fetchOne(up.url, escapedPath, name)
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (d *FallthroughDownloader) fetchOne(upstream, escapedPath, name string) ([]byte, error) {
+	u := fmt.Sprintf("%s/%s/@v/%s", upstream, escapedPath, name)
+	resp, err := d.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, os.ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("fetching %s: %s: %s", u, resp.Status, body)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// FallthroughBackend combines FallthroughDownloader and S3Copier to
+// implement Backend for an S3 bucket running in cache-through mode.
+type FallthroughBackend struct {
+	*FallthroughDownloader
+	*S3Copier
+}
+
+// NewFallthroughBackend initializes a FallthroughBackend backed by bucket,
+// falling through to the GOPROXY-style list of proxies in upstream on a
+// cache miss.
+func NewFallthroughBackend(s *session.Session, bucket, upstream string) *FallthroughBackend {
+	return &FallthroughBackend{
+		FallthroughDownloader: NewFallthroughDownloader(s, bucket, upstream),
+		S3Copier:              NewS3Copier(s, bucket),
+	}
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// FSBackend serves and populates module artifacts from a directory tree on
+// the local filesystem, mirroring the modules/<path>/@v/<name> layout used
+// by the other backends. Use NewFSBackend to initialize one.
+type FSBackend struct {
+	root string
+}
+
+func NewFSBackend(root string) *FSBackend {
+	return &FSBackend{root: root}
+}
+
+func (b *FSBackend) path(modulePath, name string) string {
+	return filepath.Join(b.root, "modules", modulePath, "@v", name)
+}
+
+// Download downloads a module artifact from the directory tree. modulePath
+// is the import path of the module, e.g. golang.org/x/text. name is the
+// asset's name such as v0.3.0.info, v0.3.0.mod, v0.3.0.ziphash, or
+// v0.3.0.zip.
+func (b *FSBackend) Download(modulePath string, name string) (io.ReadCloser, error) {
+	return os.Open(b.path(modulePath, name))
+}
+
+// HeadObject reports whether the named artifact exists on disk.
+func (b *FSBackend) HeadObject(modulePath string, name string) (bool, error) {
+	_, err := os.Stat(b.path(modulePath, name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListKeys lists the artifact names stored under modulePath's @v directory.
+func (b *FSBackend) ListKeys(modulePath string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(b.root, "modules", modulePath, "@v"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		keys = append(keys, e.Name())
+	}
+	return keys, nil
+}
+
+// ListVersions returns the known versions of modulePath.
+func (b *FSBackend) ListVersions(modulePath string) ([]string, error) {
+	keys, err := b.ListKeys(modulePath)
+	if err != nil {
+		return nil, err
+	}
+	return versionsFromInfoKeys(keys), nil
+}
+
+// GetSumDB fetches a cached checksum database record stored at key. It
+// returns an error satisfying errors.Is(err, os.ErrNotExist) on a cache
+// miss.
+func (b *FSBackend) GetSumDB(key string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(b.root, key))
+}
+
+// PutSumDB caches a checksum database record at key.
+func (b *FSBackend) PutSumDB(key string, data []byte) error {
+	dest := filepath.Join(b.root, key)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0644)
+}
+
+// Copy will run go mod download locally for the given
+// module and copy artifacts into the directory tree. Copy will
+// ensure all transient dependencies are copied.
+func (b *FSBackend) Copy(force bool, m module.Version) error {
+	return copyModule(force, m, b.exists, b.upload)
+}
+
+func (b *FSBackend) exists(key string) (bool, error) {
+	_, err := os.Stat(filepath.Join(b.root, key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *FSBackend) upload(key string, f *os.File) error {
+	dest := filepath.Join(b.root, key)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("creating %q: %w", dest, err)
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, f)
+	return err
+}
+
+// DeleteModule removes every artifact stored for m, e.g. its .info, .mod,
+// .zip, and .ziphash.
+func (b *FSBackend) DeleteModule(m module.Version) error {
+	dir := filepath.Join(b.root, "modules", m.Path, "@v")
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return os.ErrNotExist
+	}
+	if err != nil {
+		return err
+	}
+
+	prefix := m.Version + "."
+	var deleted int
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+		deleted++
+	}
+	if deleted == 0 {
+		return os.ErrNotExist
+	}
+	return nil
+}
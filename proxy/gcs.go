@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/mod/module"
+	"google.golang.org/api/iterator"
+)
+
+// GCSBackend serves and populates module artifacts backed by a Google
+// Cloud Storage bucket. Use NewGCSBackend to initialize one.
+type GCSBackend struct {
+	bucket string
+	client *storage.Client
+}
+
+func NewGCSBackend(ctx context.Context, bucket string) (*GCSBackend, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &GCSBackend{
+		bucket: bucket,
+		client: client,
+	}, nil
+}
+
+func (b *GCSBackend) object(modulePath, name string) *storage.ObjectHandle {
+	return b.client.Bucket(b.bucket).Object(fmt.Sprintf("modules/%s/@v/%s", modulePath, name))
+}
+
+// Download downloads a module artifact from the bucket. modulePath is the
+// import path of the module, e.g. golang.org/x/text. name is the asset's
+// name such as v0.3.0.info, v0.3.0.mod, v0.3.0.ziphash, or v0.3.0.zip.
+func (b *GCSBackend) Download(modulePath string, name string) (io.ReadCloser, error) {
+	return b.object(modulePath, name).NewReader(context.Background())
+}
+
+// HeadObject reports whether the named artifact exists in the bucket.
+func (b *GCSBackend) HeadObject(modulePath string, name string) (bool, error) {
+	_, err := b.object(modulePath, name).Attrs(context.Background())
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListKeys lists the artifact names stored under modulePath's @v directory.
+func (b *GCSBackend) ListKeys(modulePath string) ([]string, error) {
+	prefix := fmt.Sprintf("modules/%s/@v/", modulePath)
+
+	var keys []string
+	it := b.client.Bucket(b.bucket).Objects(context.Background(), &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, attrs.Name[len(prefix):])
+	}
+	return keys, nil
+}
+
+// ListVersions returns the known versions of modulePath.
+func (b *GCSBackend) ListVersions(modulePath string) ([]string, error) {
+	keys, err := b.ListKeys(modulePath)
+	if err != nil {
+		return nil, err
+	}
+	return versionsFromInfoKeys(keys), nil
+}
+
+// GetSumDB fetches a cached checksum database record stored at key. It
+// returns an error satisfying errors.Is(err, os.ErrNotExist) on a cache
+// miss.
+func (b *GCSBackend) GetSumDB(key string) ([]byte, error) {
+	r, err := b.client.Bucket(b.bucket).Object(key).NewReader(context.Background())
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// PutSumDB caches a checksum database record at key.
+func (b *GCSBackend) PutSumDB(key string, data []byte) error {
+	ctx := context.Background()
+	w := b.client.Bucket(b.bucket).Object(key).NewWriter(ctx)
+	if _, err := io.Copy(w, bytes.NewReader(data)); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Copy will run go mod download locally for the given
+// module and upload artifacts to the bucket. Copy will
+// ensure all transient dependencies are copied.
+func (b *GCSBackend) Copy(force bool, m module.Version) error {
+	return copyModule(force, m, b.exists, b.upload)
+}
+
+func (b *GCSBackend) exists(key string) (bool, error) {
+	_, err := b.client.Bucket(b.bucket).Object(key).Attrs(context.Background())
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *GCSBackend) upload(key string, f *os.File) error {
+	ctx := context.Background()
+	w := b.client.Bucket(b.bucket).Object(key).NewWriter(ctx)
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// DeleteModule removes every artifact stored for m, e.g. its .info, .mod,
+// .zip, and .ziphash.
+func (b *GCSBackend) DeleteModule(m module.Version) error {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("modules/%s/@v/%s.", m.Path, m.Version)
+
+	var deleted int
+	it := b.client.Bucket(b.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := b.client.Bucket(b.bucket).Object(attrs.Name).Delete(ctx); err != nil {
+			return err
+		}
+		deleted++
+	}
+	if deleted == 0 {
+		return os.ErrNotExist
+	}
+	return nil
+}
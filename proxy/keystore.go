@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// AccessKey is an admin API credential: an 8-byte access key ID paired
+// with a 32-byte secret used to HMAC-sign requests.
+type AccessKey struct {
+	AccessKeyID string `json:"accessKeyId"`
+	SecretKey   []byte `json:"secretKey"`
+}
+
+// GenerateAccessKey creates a new random AccessKey.
+func GenerateAccessKey() (*AccessKey, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &AccessKey{
+		AccessKeyID: hex.EncodeToString(id),
+		SecretKey:   secret,
+	}, nil
+}
+
+// MintKey generates a new AccessKey and persists it in keys. It's the
+// operation the authenticated POST /keys admin endpoint performs; it's
+// exported so an operator can also run it offline (e.g. via a -mint-key
+// flag) to create the first key for an empty KeyStore, before any
+// request can authenticate against it.
+func MintKey(keys KeyStore) (*AccessKey, error) {
+	ak, err := GenerateAccessKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := keys.Put(ak); err != nil {
+		return nil, err
+	}
+	return ak, nil
+}
+
+// KeyStore persists AccessKeys used to authenticate admin API requests.
+// Get returns an error satisfying errors.Is(err, os.ErrNotExist) when
+// accessKeyID is unknown.
+type KeyStore interface {
+	Get(accessKeyID string) (*AccessKey, error)
+	Put(key *AccessKey) error
+}
+
+// S3KeyStore persists AccessKeys in S3, under the _admin/keys/ prefix of
+// the same bucket used for module artifacts. Use NewS3KeyStore to
+// initialize one.
+type S3KeyStore struct {
+	bucket string
+	client *s3.S3
+}
+
+func NewS3KeyStore(s *session.Session, bucket string) *S3KeyStore {
+	return &S3KeyStore{
+		bucket: bucket,
+		client: s3.New(s),
+	}
+}
+
+func (k *S3KeyStore) key(accessKeyID string) string {
+	return "_admin/keys/" + accessKeyID
+}
+
+func (k *S3KeyStore) Get(accessKeyID string) (*AccessKey, error) {
+	o, err := k.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(k.bucket),
+		Key:    aws.String(k.key(accessKeyID)),
+	})
+	if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == errCodeNotFound || aerr.Code() == s3.ErrCodeNoSuchKey) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer o.Body.Close()
+
+	var ak AccessKey
+	if err := json.NewDecoder(o.Body).Decode(&ak); err != nil {
+		return nil, err
+	}
+	return &ak, nil
+}
+
+func (k *S3KeyStore) Put(ak *AccessKey) error {
+	data, err := json.Marshal(ak)
+	if err != nil {
+		return err
+	}
+	_, err = k.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(k.bucket),
+		Key:    aws.String(k.key(ak.AccessKeyID)),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+// FSKeyStore persists AccessKeys under the _admin/keys/ directory of a
+// local directory tree. Use NewFSKeyStore to initialize one.
+type FSKeyStore struct {
+	root string
+}
+
+func NewFSKeyStore(root string) *FSKeyStore {
+	return &FSKeyStore{root: root}
+}
+
+func (k *FSKeyStore) path(accessKeyID string) string {
+	return filepath.Join(k.root, "_admin", "keys", accessKeyID)
+}
+
+func (k *FSKeyStore) Get(accessKeyID string) (*AccessKey, error) {
+	data, err := ioutil.ReadFile(k.path(accessKeyID))
+	if err != nil {
+		return nil, err
+	}
+	var ak AccessKey
+	if err := json.Unmarshal(data, &ak); err != nil {
+		return nil, err
+	}
+	return &ak, nil
+}
+
+func (k *FSKeyStore) Put(ak *AccessKey) error {
+	dest := k.path(ak.AccessKeyID)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(ak)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0600)
+}
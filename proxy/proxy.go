@@ -2,6 +2,7 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
@@ -9,15 +10,24 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 )
 
 type ProxyHandler struct {
-	Downloader *Downloader
-	// TODO(jbd): Allow downloader to be an interface and multiple
-	// vendor implementations are available.
+	Downloader Downloader
+
+	// Presign, when set, redirects requests whose extension is in
+	// PresignExtensions (e.g. ".zip") to a time-limited URL instead of
+	// streaming the artifact through the proxy process. Artifacts whose
+	// extension is not in PresignExtensions, and list requests, are
+	// streamed.
+	Presign           PresignDownloader
+	PresignTTL        time.Duration
+	PresignExtensions map[string]bool
 }
 
 // ServeHTTP implement a Go proxy server handler.
@@ -30,6 +40,25 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if strings.HasSuffix(r.URL.Path, "/@latest") {
+		modPath, err := module.UnescapePath(strings.TrimPrefix(strings.TrimSuffix(r.URL.Path, "/@latest"), "/"))
+		if err != nil {
+			h.handleError(w, r, err)
+			return
+		}
+		f, err := h.Latest(ctx, modPath)
+		if err != nil {
+			h.handleError(w, r, err)
+			return
+		}
+		defer f.Close()
+		w.Header().Set("Content-Type", contentTypeJSON)
+		if _, err := io.Copy(w, f); err != nil {
+			h.handleError(w, r, err)
+		}
+		return
+	}
+
 	i := strings.Index(r.URL.Path, "/@v/")
 	if i < 0 {
 		http.Error(w, "no path", http.StatusBadRequest)
@@ -46,9 +75,6 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var ctype string
 	var f io.ReadCloser
 	switch what {
-	case "latest":
-		err = errors.New("latest is not supported")
-
 	case "list":
 		ctype = contentTypeText
 		f, err = h.List(ctx, modPath)
@@ -73,6 +99,30 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if h.Presign != nil && h.PresignExtensions[ext] {
+			present := true
+			if hd, ok := h.Downloader.(HeadObjecter); ok {
+				present, err = hd.HeadObject(modPath, version+ext)
+				if err != nil {
+					h.handleError(w, r, err)
+					return
+				}
+			}
+			// Only redirect to a presigned URL once the artifact is
+			// actually in the bucket; otherwise fall through to the
+			// normal streaming path below so a cache-through
+			// Downloader gets a chance to fetch and cache it first.
+			if present {
+				url, err := h.Presign.PresignDownload(modPath, version+ext, h.PresignTTL)
+				if err != nil {
+					h.handleError(w, r, err)
+					return
+				}
+				http.Redirect(w, r, url, http.StatusFound)
+				return
+			}
+		}
+
 		switch ext {
 		case ".info":
 			ctype = contentTypeJSON
@@ -117,14 +167,40 @@ func (h *ProxyHandler) handleError(w http.ResponseWriter, r *http.Request, err e
 	http.Error(w, err.Error(), code)
 }
 
-// List returns the module listing. Module path is in the
-// format of golang.org/x/text.
+// List returns the module listing: a newline-separated list of all known
+// versions. Module path is in the format of golang.org/x/text.
 func (h *ProxyHandler) List(ctx context.Context, modulePath string) (io.ReadCloser, error) {
-	path, err := module.EscapePath(modulePath)
+	versions, err := h.Downloader.ListVersions(modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, v := range versions {
+		buf.WriteString(v)
+		buf.WriteByte('\n')
+	}
+	return io.NopCloser(&buf), nil
+}
+
+// Latest returns the .info metadata for the highest semver version of the
+// module. Module path is in the format of golang.org/x/text.
+func (h *ProxyHandler) Latest(ctx context.Context, modulePath string) (io.ReadCloser, error) {
+	versions, err := h.Downloader.ListVersions(modulePath)
 	if err != nil {
 		return nil, err
 	}
-	return h.Downloader.Download(path, "listproxy")
+	if len(versions) == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	latest := versions[0]
+	for _, v := range versions[1:] {
+		if semver.Compare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return h.Downloader.Download(modulePath, latest+".info")
 }
 
 // Info returns the module .info for the specified version.
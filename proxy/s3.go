@@ -1,14 +1,14 @@
 package proxy
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"io/fs"
-	"log"
-	"net/http"
+	"io/ioutil"
+	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -19,7 +19,7 @@ import (
 )
 
 // S3Downloader reads a Go module from an S3 bucket.
-// Use NewDownloader to initialize one.
+// Use NewS3Downloader to initialize one.
 type S3Downloader struct {
 	bucket string
 	client *s3.S3
@@ -46,17 +46,132 @@ func (d *S3Downloader) Download(modulePath string, name string) (io.ReadCloser,
 	return o.Body, nil
 }
 
-// S3Copier copies a module to S3. Use NewCopier to initiate one.
+// DownloadVersion downloads the revision of modulePath's name artifact
+// identified by versionID, as opposed to its current one. It requires the
+// bucket to have versioning enabled.
+func (d *S3Downloader) DownloadVersion(modulePath, name, versionID string) (io.ReadCloser, error) {
+	o, err := d.client.GetObject(&s3.GetObjectInput{
+		Bucket:    aws.String(d.bucket),
+		Key:       aws.String(fmt.Sprintf("modules/%s/@v/%s", modulePath, name)),
+		VersionId: aws.String(versionID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o.Body, nil
+}
+
+// History returns the stored revisions of modulePath's name artifact,
+// most recent first. It requires the bucket to have versioning enabled.
+func (d *S3Downloader) History(modulePath, name string) ([]ArtifactVersion, error) {
+	key := fmt.Sprintf("modules/%s/@v/%s", modulePath, name)
+
+	var versions []ArtifactVersion
+	err := d.client.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+		Bucket: aws.String(d.bucket),
+		Prefix: aws.String(key),
+	}, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, v := range page.Versions {
+			if aws.StringValue(v.Key) != key {
+				continue
+			}
+			versions = append(versions, ArtifactVersion{
+				VersionID:    aws.StringValue(v.VersionId),
+				LastModified: aws.TimeValue(v.LastModified),
+				IsLatest:     aws.BoolValue(v.IsLatest),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// HeadObject reports whether the named artifact exists in the bucket.
+func (d *S3Downloader) HeadObject(modulePath string, name string) (bool, error) {
+	_, err := d.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(fmt.Sprintf("modules/%s/@v/%s", modulePath, name)),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == errCodeNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListKeys lists the artifact names stored under modulePath's @v directory.
+func (d *S3Downloader) ListKeys(modulePath string) ([]string, error) {
+	prefix := fmt.Sprintf("modules/%s/@v/", modulePath)
+
+	var keys []string
+	err := d.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(d.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, o := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.StringValue(o.Key), prefix))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ListVersions returns the known versions of modulePath.
+func (d *S3Downloader) ListVersions(modulePath string) ([]string, error) {
+	keys, err := d.ListKeys(modulePath)
+	if err != nil {
+		return nil, err
+	}
+	return versionsFromInfoKeys(keys), nil
+}
+
+// PresignDownload returns a presigned URL from which the named artifact
+// can be downloaded directly from S3 until ttl elapses.
+func (d *S3Downloader) PresignDownload(modulePath, name string, ttl time.Duration) (string, error) {
+	req, _ := d.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(fmt.Sprintf("modules/%s/@v/%s", modulePath, name)),
+	})
+	return req.Presign(ttl)
+}
+
+// GetSumDB fetches a cached checksum database record stored at key. It
+// returns an error satisfying errors.Is(err, os.ErrNotExist) on a cache
+// miss.
+func (d *S3Downloader) GetSumDB(key string) ([]byte, error) {
+	o, err := d.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == errCodeNotFound || aerr.Code() == s3.ErrCodeNoSuchKey) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer o.Body.Close()
+	return ioutil.ReadAll(o.Body)
+}
+
+// S3Copier copies a module to S3. Use NewS3Copier to initiate one.
 type S3Copier struct {
-	// TODO(jbd): Allow Copier to be an abstract type to support
-	// vendors other than S3.
 	bucket   string
+	client   *s3.S3
 	uploader *s3manager.Uploader
 }
 
 func NewS3Copier(s *session.Session, bucket string) *S3Copier {
 	return &S3Copier{
 		bucket:   bucket,
+		client:   s3.New(s),
 		uploader: s3manager.NewUploader(s),
 	}
 }
@@ -65,89 +180,92 @@ func NewS3Copier(s *session.Session, bucket string) *S3Copier {
 // module and upload artifacts to S3. Copy will
 // ensure all transient dependencies are copied.
 func (c *S3Copier) Copy(force bool, m module.Version) error {
-	log.Printf("Resolving module: %s", m)
-	info, err := goModDownload(m)
-	if err != nil {
-		return err
-	}
-	defer os.RemoveAll(info.Cache)
+	return copyModule(force, m, c.exists, c.upload)
+}
 
-	// Downloads all transient dependencies.
-	if err := goModDownloadAll(info.Cache, info.GoMod); err != nil {
-		return err
+func (c *S3Copier) exists(key string) (bool, error) {
+	_, err := c.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == errCodeNotFound {
+		return false, nil
 	}
+	return err == nil, err
+}
 
-	assetsDir := filepath.Join(info.Cache, "cache", "download")
-	if err := filepath.Walk(assetsDir, func(path string, info fs.FileInfo, err error) error {
-		if !shouldUpload(info) {
-			return nil
-		}
-		o := strings.Replace(path, assetsDir, "", 1)
-		return c.upload(force, path, o)
-	}); err != nil {
-		return err
-	}
-	return nil
+func (c *S3Copier) upload(key string, f *os.File) error {
+	_, err := c.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+		Body:   f,
+	})
+	return err
 }
 
-func (c *S3Copier) upload(force bool, src string, dest string) error {
-	f, err := os.OpenFile(src, os.O_RDONLY, 0)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+// Rollback replaces the current revision of modulePath's name artifact
+// with the contents of versionID, undoing a later Copy(force=true) that
+// overwrote it with a bad upload. It performs a server-side copy of the
+// chosen revision on top of the current key.
+func (c *S3Copier) Rollback(modulePath, name, versionID string) error {
+	key := fmt.Sprintf("modules/%s/@v/%s", modulePath, name)
+	_, err := c.client.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(c.bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(fmt.Sprintf("%s/%s?versionId=%s", c.bucket, url.QueryEscape(key), versionID)),
+	})
+	return err
+}
+
+// PutSumDB caches a checksum database record at key.
+func (c *S3Copier) PutSumDB(key string, data []byte) error {
+	_, err := c.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
 
-	key := "modules" + dest
+// DeleteModule removes every artifact stored for m, e.g. its .info, .mod,
+// .zip, and .ziphash.
+func (c *S3Copier) DeleteModule(m module.Version) error {
+	prefix := fmt.Sprintf("modules/%s/@v/%s.", m.Path, m.Version)
 
-	uploader := func() error {
-		log.Printf("Uploading %q", key)
-		_, err = c.uploader.Upload(&s3manager.UploadInput{
-			Bucket: aws.String(c.bucket),
-			Key:    aws.String(key),
-			Body:   f,
-		})
+	var objs []*s3.ObjectIdentifier
+	err := c.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, o := range page.Contents {
+			objs = append(objs, &s3.ObjectIdentifier{Key: o.Key})
+		}
+		return true
+	})
+	if err != nil {
 		return err
 	}
-
-	if force {
-		return uploader()
+	if len(objs) == 0 {
+		return os.ErrNotExist
 	}
 
-	log.Printf("Checking if %q exists", key)
-	_, err = c.uploader.S3.HeadObject(&s3.HeadObjectInput{
+	_, err = c.client.DeleteObjects(&s3.DeleteObjectsInput{
 		Bucket: aws.String(c.bucket),
-		Key:    aws.String(key),
+		Delete: &s3.Delete{Objects: objs},
 	})
-	if aerr, ok := err.(awserr.Error); ok {
-		if aerr.Code() == errCodeNotFound {
-			return uploader()
-		}
-	}
 	return err
 }
 
-func (c *S3Copier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// POST http://localhost:9999/golang.org/x/text@v3.0.1
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
-	}
-
-	path, version, ok := parseURLPathForModule(r.URL.Path)
-	if !ok {
-		http.Error(w, "malformed module path or version", http.StatusBadRequest)
-		return
-	}
+// S3Backend combines S3Downloader and S3Copier to implement Backend for
+// Amazon S3 and S3-compatible object stores.
+type S3Backend struct {
+	*S3Downloader
+	*S3Copier
+}
 
-	var force bool
-	if f := r.URL.Query().Get("f"); f == "true" {
-		force = true
-	}
-	if err := c.Copy(force, module.Version{Path: path, Version: version}); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+func NewS3Backend(s *session.Session, bucket string) *S3Backend {
+	return &S3Backend{
+		S3Downloader: NewS3Downloader(s, bucket),
+		S3Copier:     NewS3Copier(s, bucket),
 	}
-
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintln(w, "ok")
 }
@@ -19,12 +19,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
-	"net/http"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"golang.org/x/mod/module"
 )
@@ -37,14 +39,100 @@ const (
 
 const errCodeNotFound = "NotFound" // See https://github.com/aws/aws-sdk-go/issues/1208.
 
+// Downloader reads a Go module artifact from a storage backend. modulePath
+// is the import path of the module, e.g. golang.org/x/text. name is the
+// asset's name such as v0.3.0.info, v0.3.0.mod, v0.3.0.ziphash, or
+// v0.3.0.zip.
 type Downloader interface {
 	Download(modulePath string, name string) (io.ReadCloser, error)
+
+	// ListVersions returns the known versions of modulePath, e.g.
+	// "v0.1.0", "v0.3.0", typically derived from the *.info artifacts
+	// stored for it. Order is unspecified.
+	ListVersions(modulePath string) ([]string, error)
 }
 
+// Copier runs go mod download for a module and persists the resolved
+// artifacts to a storage backend.
 type Copier interface {
 	Copy(force bool, m module.Version) error
-	ServeHTTP(w http.ResponseWriter, r *http.Request)
-	// TODO(jbd): Remove ServeHTTP from Copier.
+}
+
+// HeadObjecter reports whether a module artifact is already present in a
+// storage backend, without fetching its contents.
+type HeadObjecter interface {
+	HeadObject(modulePath string, name string) (bool, error)
+}
+
+// Lister enumerates the artifact names stored under a module's @v
+// directory, e.g. "v0.3.0.info", "v0.3.0.mod", "v0.3.0.zip".
+type Lister interface {
+	ListKeys(modulePath string) ([]string, error)
+}
+
+// Backend is a storage backend capable of serving and populating module
+// artifacts for the proxy. S3, GCS, Azure Blob, and the local filesystem
+// driver all implement Backend.
+type Backend interface {
+	Downloader
+	Copier
+	HeadObjecter
+	Lister
+}
+
+// ModuleDeleter removes every artifact stored for a specific module
+// version, e.g. its .info, .mod, .zip, and .ziphash. It returns an error
+// satisfying errors.Is(err, os.ErrNotExist) if no artifacts were found.
+type ModuleDeleter interface {
+	DeleteModule(m module.Version) error
+}
+
+// PresignDownloader is implemented by backends that can hand out a
+// time-limited URL from which an artifact can be fetched directly, instead
+// of streaming it through the proxy process. ProxyHandler uses it to
+// redirect large downloads such as .zip files.
+type PresignDownloader interface {
+	PresignDownload(modulePath, name string, ttl time.Duration) (string, error)
+}
+
+// ArtifactVersion describes one stored revision of a module artifact, as
+// reported by a versioned storage backend such as an S3 bucket with
+// versioning enabled.
+type ArtifactVersion struct {
+	VersionID    string
+	LastModified time.Time
+	IsLatest     bool
+}
+
+// VersionedBackend is implemented by backends whose storage keeps
+// multiple revisions of an object, such as an S3 bucket with versioning
+// enabled. It lets operators fetch a specific prior revision of an
+// artifact, inspect its revision history, and roll back to a prior
+// revision after a bad Copy(force=true) overwrites good artifacts with
+// broken ones.
+type VersionedBackend interface {
+	// DownloadVersion downloads the revision of modulePath's name
+	// artifact identified by versionID, as opposed to its current one.
+	DownloadVersion(modulePath, name, versionID string) (io.ReadCloser, error)
+
+	// History returns modulePath's name artifact's stored revisions,
+	// most recent first.
+	History(modulePath, name string) ([]ArtifactVersion, error)
+
+	// Rollback replaces the current revision of modulePath's name
+	// artifact with the contents of versionID, undoing a later
+	// Copy(force=true) that overwrote it.
+	Rollback(modulePath, name, versionID string) error
+}
+
+// SumDBCache stores raw checksum database records, keyed by their full
+// request path under the sumdb/ prefix, e.g.
+// "sumdb/sum.golang.org/lookup/golang.org/x/text@v0.3.0". SumDBProxy uses
+// it to cache upstream lookups. S3, GCS, Azure Blob, and the local
+// filesystem driver all implement SumDBCache.
+type SumDBCache interface {
+	GetSumDB(key string) ([]byte, error)
+	PutSumDB(key string, data []byte) error
 }
 
 type moduleInfo struct {
@@ -139,3 +227,76 @@ func shouldUpload(fi os.FileInfo) bool {
 	}
 	return false
 }
+
+// versionsFromInfoKeys filters keys (artifact names under a module's @v
+// directory, as returned by Lister.ListKeys) down to the version stems of
+// their .info files, e.g. "v0.3.0.info" -> "v0.3.0".
+func versionsFromInfoKeys(keys []string) []string {
+	var versions []string
+	for _, k := range keys {
+		if v := strings.TrimSuffix(k, ".info"); v != k {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// uploadFunc persists the file at src under the backend-specific key
+// "modules"+dest, where dest is the artifact's path relative to the module
+// cache download directory, e.g. "/golang.org/x/text/@v/v0.3.0.zip".
+type uploadFunc func(key string, src *os.File) error
+
+// existsFunc reports whether key is already present in the backend.
+type existsFunc func(key string) (bool, error)
+
+// copyModule runs `go mod download` for m, resolves all of its transient
+// dependencies, and calls upload for every artifact that shouldUpload
+// selects, skipping those exists reports present unless force is set. Concrete Copier implementations share this so the
+// go-mod-download-and-walk logic isn't duplicated per backend.
+func copyModule(force bool, m module.Version, exists existsFunc, upload uploadFunc) error {
+	log.Printf("Resolving module: %s", m)
+	info, err := goModDownload(m)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(info.Cache)
+
+	// Downloads all transient dependencies.
+	if err := goModDownloadAll(info.Cache, info.GoMod); err != nil {
+		return err
+	}
+
+	assetsDir := filepath.Join(info.Cache, "cache", "download")
+	return filepath.Walk(assetsDir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !shouldUpload(info) {
+			return nil
+		}
+		key := "modules" + strings.Replace(path, assetsDir, "", 1)
+		return uploadIfNeeded(force, path, key, exists, upload)
+	})
+}
+
+func uploadIfNeeded(force bool, src, key string, exists existsFunc, upload uploadFunc) error {
+	if !force {
+		log.Printf("Checking if %q exists", key)
+		ok, err := exists(key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	f, err := os.OpenFile(src, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	log.Printf("Uploading %q", key)
+	return upload(key, f)
+}
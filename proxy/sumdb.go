@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// SumDBMode selects how a SumDBProxy serves GOSUMDB checksum database
+// requests.
+type SumDBMode int
+
+const (
+	// SumDBOff disables the sumdb/ endpoint; ServeHTTP replies 404.
+	SumDBOff SumDBMode = iota
+	// SumDBProxyMode transparently forwards requests to Upstream and
+	// caches the response as-is, signature included. Clients still
+	// verify against Upstream's notary key, so the proxy never needs to
+	// reason about the checksum database's Merkle tree itself.
+	SumDBProxyMode
+)
+
+// SumDBProxy serves the /sumdb/<name>/... endpoints of the Go checksum
+// database protocol (see golang.org/x/mod/sumdb), caching responses into a
+// SumDBCache under a sumdb/ key prefix. Requests are forwarded to Upstream
+// with the leading /sumdb/<name> stripped, since Upstream is a raw GOSUMDB
+// server (e.g. https://sum.golang.org) that only serves /lookup/...,
+// /latest, and /tile/..., not the /sumdb/-prefixed proxy paths.
+//
+// There is intentionally no mode that re-signs records under a local key:
+// doing so safely requires serving a real Merkle tree (golang.org/x/mod/
+// sumdb/tlog) with consistent /latest and /tile/... responses, not just
+// the /lookup/ record, and this proxy doesn't maintain one. Re-signing
+// only the lookup record would let a client's local GOSUMDB key accept a
+// record whose inclusion it can never actually prove.
+type SumDBProxy struct {
+	Mode     SumDBMode
+	Upstream string // raw GOSUMDB server, e.g. "https://sum.golang.org"
+	Cache    SumDBCache
+
+	client *http.Client
+}
+
+func NewSumDBProxy(mode SumDBMode, upstream string, cache SumDBCache) *SumDBProxy {
+	return &SumDBProxy{
+		Mode:     mode,
+		Upstream: strings.TrimSuffix(upstream, "/"),
+		Cache:    cache,
+		client:   http.DefaultClient,
+	}
+}
+
+func (s *SumDBProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Mode == SumDBOff {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	key := "sumdb/" + strings.TrimPrefix(r.URL.Path, "/sumdb/")
+
+	data, err := s.Cache.GetSumDB(key)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		data, err = s.fetch(upstreamPath(r.URL.Path))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if err := s.Cache.PutSumDB(key, data); err != nil {
+			log.Printf("sumdb: caching %q failed: %v", key, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", contentTypeText)
+	w.Write(data)
+}
+
+func (s *SumDBProxy) fetch(path string) ([]byte, error) {
+	resp, err := s.client.Get(s.Upstream + path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream sumdb returned %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// upstreamPath strips the /sumdb/<name> prefix the go command's proxy
+// protocol puts on sumdb requests (see golang.org/x/mod/sumdb), returning
+// the path a raw GOSUMDB server expects, e.g. "/lookup/...", "/latest", or
+// "/tile/...".
+func upstreamPath(urlPath string) string {
+	rest := strings.TrimPrefix(urlPath, "/sumdb/")
+	if i := strings.Index(rest, "/"); i >= 0 {
+		return rest[i:]
+	}
+	return "/"
+}